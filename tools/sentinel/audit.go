@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditGenesisHash seeds the chain before any entry exists. Any real
+// SHA-256 digest is equally valid as a starting point; an all-zero value
+// just makes "this is the first entry" obvious when reading the log by eye.
+var auditGenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// AuditEntry is one record in the tamper-evident log: everything the
+// Sentinel knew about a verified /execute call, plus the hash that chains
+// it to the entry before it.
+type AuditEntry struct {
+	Seq                   uint64 `json:"seq"`
+	PrevHash              string `json:"prev_hash"`
+	Timestamp             int64  `json:"timestamp"`
+	ClientCertFingerprint string `json:"client_cert_fingerprint"`
+	KID                   string `json:"kid"`
+	Nonce                 string `json:"nonce"`
+	PayloadHash           string `json:"payload_hash"`
+	Verdict               string `json:"verdict"`
+	// ExecutorResult is whatever ExecResult snapshot was available when this
+	// entry was appended. For the entry appended from the /execute handler
+	// itself, the action has usually only just started, so this is the
+	// immediate "running" snapshot, not the final outcome; a second entry
+	// with Verdict "completed:<status>" is appended once the dispatcher
+	// goroutine finishes, carrying the real exit code/output/duration.
+	ExecutorResult string `json:"executor_result,omitempty"`
+	Hash           string `json:"hash"`
+}
+
+// canonicalizeAuditEntry serializes every field except Hash itself, in a
+// fixed field order, so hashing and verifying a chain link never disagree
+// about encoding.
+func canonicalizeAuditEntry(e AuditEntry) []byte {
+	return []byte(fmt.Sprintf(
+		`{"client_cert_fingerprint":%q,"executor_result":%q,"kid":%q,"nonce":%q,"payload_hash":%q,"prev_hash":%q,"seq":%d,"timestamp":%d,"verdict":%q}`,
+		e.ClientCertFingerprint, e.ExecutorResult, e.KID, e.Nonce, e.PayloadHash, e.PrevHash, e.Seq, e.Timestamp, e.Verdict,
+	))
+}
+
+func chainHash(prevHash string, e AuditEntry) string {
+	sum := sha256.Sum256(append([]byte(prevHash), canonicalizeAuditEntry(e)...))
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditLog is an append-only, hash-chained record of every verified
+// /execute call, with size-based rotation so a single file can't grow
+// without bound.
+type AuditLog struct {
+	mu           sync.Mutex
+	path         string
+	file         *os.File
+	maxSizeBytes int64
+	seq          uint64
+	lastHash     string
+}
+
+// auditAnchor is the sidecar record written alongside path whenever the log
+// rotates: the tip hash/seq of the segment that was just rotated away, so a
+// fresh file doesn't have to be trusted blind. Without this, deleting the
+// active file's leading entries (or the whole file, post-rotation) and
+// letting verification treat whatever's left as its own anchor would hide
+// the truncation entirely.
+type auditAnchor struct {
+	AnchorHash  string `json:"anchor_hash"`
+	AnchorSeq   uint64 `json:"anchor_seq"`
+	RotatedFrom string `json:"rotated_from,omitempty"`
+}
+
+func auditAnchorPath(path string) string { return path + ".anchor" }
+
+// loadAuditAnchor reads the anchor sidecar for path, if one exists. A nil,
+// nil return means path has never been rotated, so its expected anchor is
+// auditGenesisHash at seq 0.
+func loadAuditAnchor(path string) (*auditAnchor, error) {
+	data, err := os.ReadFile(auditAnchorPath(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit anchor for %s: %v", path, err)
+	}
+	var a auditAnchor
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("failed to parse audit anchor for %s: %v", path, err)
+	}
+	return &a, nil
+}
+
+func saveAuditAnchor(path string, a auditAnchor) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(auditAnchorPath(path), data, 0o600)
+}
+
+// openAuditLog opens (or creates) the log at path, replaying its existing
+// entries to recover the current seq and chain tip so restarts don't reset
+// the chain. If path carries an anchor sidecar from a prior rotation, that
+// anchor seeds seq/lastHash instead of genesis, so a freshly-rotated empty
+// file still continues the same chain.
+func openAuditLog(path string, maxSizeBytes int64) (*AuditLog, error) {
+	a := &AuditLog{path: path, maxSizeBytes: maxSizeBytes, lastHash: auditGenesisHash}
+
+	anchor, err := loadAuditAnchor(path)
+	if err != nil {
+		return nil, err
+	}
+	if anchor != nil {
+		a.lastHash = anchor.AnchorHash
+		a.seq = anchor.AnchorSeq
+	}
+
+	if f, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var e AuditEntry
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("failed to parse existing audit log %s: %v", path, err)
+			}
+			a.seq = e.Seq
+			a.lastHash = e.Hash
+		}
+		err := scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to replay audit log %s: %v", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open audit log %s: %v", path, err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log %s for append: %v", path, err)
+	}
+	a.file = f
+	return a, nil
+}
+
+// Append writes the next entry in the chain, filling in Seq, PrevHash,
+// Timestamp, and Hash, fsync'ing before returning so a crash can't drop an
+// acknowledged entry, and rotating the file afterward if it has grown past
+// maxSizeBytes.
+func (a *AuditLog) Append(entry AuditEntry) (AuditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.seq++
+	entry.Seq = a.seq
+	entry.PrevHash = a.lastHash
+	entry.Timestamp = time.Now().Unix()
+	entry.Hash = chainHash(entry.PrevHash, entry)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return entry, fmt.Errorf("failed to marshal audit entry: %v", err)
+	}
+	if _, err := a.file.Write(append(line, '\n')); err != nil {
+		return entry, fmt.Errorf("failed to write audit entry: %v", err)
+	}
+	if err := a.file.Sync(); err != nil {
+		return entry, fmt.Errorf("failed to fsync audit log: %v", err)
+	}
+	a.lastHash = entry.Hash
+
+	if info, err := a.file.Stat(); err == nil && a.maxSizeBytes > 0 && info.Size() >= a.maxSizeBytes {
+		if err := a.rotateLocked(); err != nil {
+			fmt.Printf("[audit] rotation failed, continuing to append to current file: %v\n", err)
+		}
+	}
+	return entry, nil
+}
+
+// rotateLocked renames the current log file aside and opens a fresh one.
+// The in-memory seq/lastHash carry forward unchanged, so the chain is still
+// continuous conceptually; it also writes an anchor sidecar recording the
+// rotated-away segment's tip, so VerifyChain and the CLI verifier don't have
+// to take the new file's first entry's prev_hash on faith.
+func (a *AuditLog) rotateLocked() error {
+	if err := a.file.Close(); err != nil {
+		return err
+	}
+	rotatedPath := fmt.Sprintf("%s.%d", a.path, time.Now().UnixNano())
+	if err := os.Rename(a.path, rotatedPath); err != nil {
+		return err
+	}
+	if err := saveAuditAnchor(a.path, auditAnchor{AnchorHash: a.lastHash, AnchorSeq: a.seq, RotatedFrom: rotatedPath}); err != nil {
+		return fmt.Errorf("failed to persist audit anchor: %v", err)
+	}
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	a.file = f
+	return nil
+}
+
+// VerifyResult is returned from both the /audit/verify endpoint and the
+// offline CLI verifier.
+type VerifyResult struct {
+	OK          bool   `json:"ok"`
+	EntriesSeen int    `json:"entries_seen"`
+	BrokenAtSeq uint64 `json:"broken_at_seq,omitempty"`
+	Message     string `json:"message"`
+}
+
+// VerifyChain walks the active log file from the beginning and reports the
+// first entry whose hash doesn't match what its predecessor and contents
+// imply, i.e., the first sign of tampering.
+func (a *AuditLog) VerifyChain() (VerifyResult, error) {
+	return verifyAuditFile(a.path)
+}
+
+// verifyAuditFile is the shared implementation behind VerifyChain and the
+// offline `sentinel audit verify` CLI, so both paths can never disagree.
+//
+// The chain's anchor for this file is never taken from the file's own first
+// entry: for a never-rotated file it must be auditGenesisHash at seq 0, and
+// for a file that continues a prior segment it must match that segment's
+// persisted anchor sidecar. Trusting whatever prev_hash happens to sit in
+// the current first line would let anyone with filesystem access truncate
+// a prefix of the log (or delete it outright, post-rotation) and have the
+// remainder verify as "chain intact".
+func verifyAuditFile(path string) (VerifyResult, error) {
+	anchor, err := loadAuditAnchor(path)
+	if err != nil {
+		return VerifyResult{}, err
+	}
+	expectedAnchorHash := auditGenesisHash
+	var expectedAnchorSeq uint64
+	if anchor != nil {
+		expectedAnchorHash = anchor.AnchorHash
+		expectedAnchorSeq = anchor.AnchorSeq
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return VerifyResult{}, fmt.Errorf("failed to open audit log %s: %v", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	seen := 0
+	var prevHash string
+	first := true
+	for scanner.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return VerifyResult{OK: false, EntriesSeen: seen, Message: fmt.Sprintf("entry %d: unparseable: %v", seen+1, err)}, nil
+		}
+		seen++
+		if first {
+			if e.PrevHash != expectedAnchorHash || e.Seq != expectedAnchorSeq+1 {
+				return VerifyResult{OK: false, EntriesSeen: seen, BrokenAtSeq: e.Seq, Message: "first entry does not chain from the expected anchor (genesis or prior segment tip) - possible truncation"}, nil
+			}
+			prevHash = e.PrevHash
+			first = false
+		}
+		if e.PrevHash != prevHash {
+			return VerifyResult{OK: false, EntriesSeen: seen, BrokenAtSeq: e.Seq, Message: "prev_hash does not match predecessor"}, nil
+		}
+		want := chainHash(prevHash, AuditEntry{
+			Seq: e.Seq, PrevHash: e.PrevHash, Timestamp: e.Timestamp,
+			ClientCertFingerprint: e.ClientCertFingerprint, KID: e.KID, Nonce: e.Nonce,
+			PayloadHash: e.PayloadHash, Verdict: e.Verdict, ExecutorResult: e.ExecutorResult,
+		})
+		if want != e.Hash {
+			return VerifyResult{OK: false, EntriesSeen: seen, BrokenAtSeq: e.Seq, Message: "hash does not match entry contents"}, nil
+		}
+		prevHash = e.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return VerifyResult{}, fmt.Errorf("failed to read audit log %s: %v", path, err)
+	}
+	return VerifyResult{OK: true, EntriesSeen: seen, Message: "chain intact"}, nil
+}
+
+// certFingerprint returns the hex SHA-256 fingerprint of a raw DER
+// certificate, the same format used by certStore's /healthz.
+func certFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// sha256Hex is a small helper for hashing payload bytes into PayloadHash.
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// runAuditCLI implements `sentinel audit verify`, the offline verifier
+// operators can run without standing up a Sentinel process.
+func runAuditCLI(args []string) {
+	if len(args) == 0 || args[0] != "verify" {
+		fmt.Println("usage: sentinel audit verify -log <path>")
+		os.Exit(1)
+	}
+	var path string
+	for i := 1; i < len(args); i++ {
+		if args[i] == "-log" && i+1 < len(args) {
+			path = args[i+1]
+		}
+	}
+	if path == "" {
+		path = "sentinel_audit.log"
+	}
+
+	result, err := verifyAuditFile(path)
+	if err != nil {
+		fmt.Printf("verify failed: %v\n", err)
+		os.Exit(1)
+	}
+	data, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(data))
+	if !result.OK {
+		os.Exit(1)
+	}
+}
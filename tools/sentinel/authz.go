@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// sentinelRoleOID is a private-enterprise OID carrying the caller's role
+// directly in the client certificate, for deployments that don't want to
+// encode it into Subject/SAN fields. Falls back to OU, then CN, when absent.
+var sentinelRoleOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57042, 1, 1}
+
+// Policy maps a role to the command verbs it's allowed to invoke.
+type Policy struct {
+	Roles map[string][]string `json:"roles"`
+}
+
+// loadPolicy reads a role->verbs policy file, e.g.:
+//
+//	{"roles": {"oncall": ["patch", "restart"], "readonly": ["snapshot"]}}
+func loadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file %s: %v", path, err)
+	}
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file %s: %v", path, err)
+	}
+	return &p, nil
+}
+
+// Allows reports whether role may invoke verb.
+func (p *Policy) Allows(role, verb string) bool {
+	for _, v := range p.Roles[role] {
+		if v == verb {
+			return true
+		}
+	}
+	return false
+}
+
+// roleFromCert extracts the caller's role from their mTLS client
+// certificate: the X-Sentinel-Role extension if present, else the first
+// Organizational Unit, else the Common Name.
+func roleFromCert(cert *x509.Certificate) (string, error) {
+	if cert == nil {
+		return "", fmt.Errorf("no client certificate presented")
+	}
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(sentinelRoleOID) {
+			// ext.Value is the DER encoding of the extension's ASN.1 value
+			// (e.g. a PrintableString/UTF8String), not a bare string, so it
+			// carries a tag/length header that a raw cast would leave in
+			// place.
+			var role string
+			if _, err := asn1.Unmarshal(ext.Value, &role); err != nil {
+				return "", fmt.Errorf("invalid X-Sentinel-Role extension encoding: %v", err)
+			}
+			return strings.TrimSpace(role), nil
+		}
+	}
+	if len(cert.Subject.OrganizationalUnit) > 0 && cert.Subject.OrganizationalUnit[0] != "" {
+		return cert.Subject.OrganizationalUnit[0], nil
+	}
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName, nil
+	}
+	return "", fmt.Errorf("client certificate carries no usable role (no X-Sentinel-Role extension, OU, or CN)")
+}
+
+// parseVerb extracts the command verb (first whitespace-delimited token)
+// from a payload like "patch --service=db", the same shape parsePayload in
+// dispatcher.go parses in full.
+func parseVerb(payload string) string {
+	fields := strings.Fields(payload)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+)
+
+func certWithRoleExtension(t *testing.T, role string) *x509.Certificate {
+	t.Helper()
+	val, err := asn1.Marshal(role)
+	if err != nil {
+		t.Fatalf("failed to marshal role extension: %v", err)
+	}
+	return &x509.Certificate{
+		Extensions: []pkix.Extension{
+			{Id: sentinelRoleOID, Value: val},
+		},
+	}
+}
+
+func TestRoleFromCertPrefersExtension(t *testing.T) {
+	cert := certWithRoleExtension(t, "oncall")
+	cert.Subject.OrganizationalUnit = []string{"should-be-ignored"}
+	cert.Subject.CommonName = "should-be-ignored"
+
+	role, err := roleFromCert(cert)
+	if err != nil {
+		t.Fatalf("roleFromCert returned error: %v", err)
+	}
+	if role != "oncall" {
+		t.Fatalf("role = %q, want %q", role, "oncall")
+	}
+}
+
+func TestRoleFromCertRejectsMalformedExtension(t *testing.T) {
+	cert := &x509.Certificate{
+		Extensions: []pkix.Extension{
+			// Not a valid ASN.1 string, just the bare bytes a naive cast
+			// would have accepted.
+			{Id: sentinelRoleOID, Value: []byte("oncall")},
+		},
+	}
+	if _, err := roleFromCert(cert); err == nil {
+		t.Fatal("expected an error for a non-ASN.1 extension value, got none")
+	}
+}
+
+func TestRoleFromCertFallsBackToOU(t *testing.T) {
+	cert := &x509.Certificate{}
+	cert.Subject.OrganizationalUnit = []string{"readonly"}
+	cert.Subject.CommonName = "should-be-ignored"
+
+	role, err := roleFromCert(cert)
+	if err != nil {
+		t.Fatalf("roleFromCert returned error: %v", err)
+	}
+	if role != "readonly" {
+		t.Fatalf("role = %q, want %q", role, "readonly")
+	}
+}
+
+func TestRoleFromCertFallsBackToCN(t *testing.T) {
+	cert := &x509.Certificate{}
+	cert.Subject.CommonName = "oncall-bot"
+
+	role, err := roleFromCert(cert)
+	if err != nil {
+		t.Fatalf("roleFromCert returned error: %v", err)
+	}
+	if role != "oncall-bot" {
+		t.Fatalf("role = %q, want %q", role, "oncall-bot")
+	}
+}
+
+func TestRoleFromCertNoUsableIdentity(t *testing.T) {
+	if _, err := roleFromCert(&x509.Certificate{}); err == nil {
+		t.Fatal("expected an error when cert carries no extension, OU, or CN")
+	}
+}
+
+func TestRoleFromCertNilCertificate(t *testing.T) {
+	if _, err := roleFromCert(nil); err == nil {
+		t.Fatal("expected an error for a nil certificate")
+	}
+}
+
+func TestPolicyAllows(t *testing.T) {
+	p := &Policy{Roles: map[string][]string{
+		"oncall":   {"patch", "restart"},
+		"readonly": {"snapshot"},
+	}}
+
+	if !p.Allows("oncall", "patch") {
+		t.Error("oncall should be allowed to patch")
+	}
+	if p.Allows("readonly", "patch") {
+		t.Error("readonly should not be allowed to patch")
+	}
+	if p.Allows("unknown-role", "patch") {
+		t.Error("an unknown role should never be allowed anything")
+	}
+}
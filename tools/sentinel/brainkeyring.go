@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// BrainKey is one entry in a BrainKeyRing: an ed25519 public key plus its
+// validity window, keyed by kid so the Brain can pre-publish a successor
+// key before cutting over to it.
+type BrainKey struct {
+	KID       string     `json:"kid"`
+	Alg       string     `json:"alg"`
+	Pub       string     `json:"pub"` // hex-encoded ed25519 public key
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	NotAfter  *time.Time `json:"not_after,omitempty"`
+	Revoked   bool       `json:"revoked"`
+}
+
+func (k BrainKey) publicKey() (ed25519.PublicKey, error) {
+	raw, err := hex.DecodeString(k.Pub)
+	if err != nil {
+		return nil, fmt.Errorf("kid %q: invalid hex pub: %v", k.KID, err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("kid %q: wrong pub size %d", k.KID, len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// BrainKeyRing holds every ed25519 public key the Brain may currently or
+// imminently sign with, loaded from a JSON/JWKS-style file on disk and kept
+// fresh by the same poll-and-reload mechanism as certStore.
+type BrainKeyRing struct {
+	path string
+
+	mu      sync.RWMutex
+	keys    map[string]BrainKey
+	lastMod time.Time
+}
+
+type brainKeyRingFile struct {
+	Keys []BrainKey `json:"keys"`
+}
+
+// loadBrainKeyRing reads and parses the keyring file at path.
+func loadBrainKeyRing(path string) (*BrainKeyRing, error) {
+	r := &BrainKeyRing{path: path}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload re-reads the keyring file from disk, validating every entry before
+// swapping it in so a malformed write-in-progress file can't wipe the ring.
+func (r *BrainKeyRing) reload() error {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return fmt.Errorf("failed to read Brain keyring from %s: %v", r.path, err)
+	}
+	var file brainKeyRingFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse Brain keyring %s: %v", r.path, err)
+	}
+	keys := make(map[string]BrainKey, len(file.Keys))
+	for _, k := range file.Keys {
+		if k.KID == "" {
+			return fmt.Errorf("Brain keyring %s: entry missing kid", r.path)
+		}
+		if _, err := k.publicKey(); err != nil {
+			return fmt.Errorf("Brain keyring %s: %v", r.path, err)
+		}
+		keys[k.KID] = k
+	}
+
+	stat, _ := os.Stat(r.path)
+	r.mu.Lock()
+	r.keys = keys
+	if stat != nil {
+		r.lastMod = stat.ModTime()
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+// needsReload reports whether the keyring file has changed since last load.
+func (r *BrainKeyRing) needsReload() bool {
+	stat, err := os.Stat(r.path)
+	if err != nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return stat.ModTime().After(r.lastMod)
+}
+
+// Errors returned by Lookup, distinguishing why a kid was rejected.
+var (
+	errKeyNotFound = fmt.Errorf("kid not found in keyring")
+	errKeyRevoked  = fmt.Errorf("kid has been revoked")
+	errKeyNotYet   = fmt.Errorf("kid is not yet valid")
+	errKeyExpired  = fmt.Errorf("kid has expired")
+)
+
+// Lookup returns the public key for kid, rejecting revoked keys and keys
+// outside their validity window so an old or pre-published key can't be
+// used before/after its intended window.
+func (r *BrainKeyRing) Lookup(kid string) (ed25519.PublicKey, error) {
+	r.mu.RLock()
+	key, ok := r.keys[kid]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, errKeyNotFound
+	}
+	if key.Revoked {
+		return nil, errKeyRevoked
+	}
+	now := time.Now()
+	if key.NotBefore != nil && now.Before(*key.NotBefore) {
+		return nil, errKeyNotYet
+	}
+	if key.NotAfter != nil && now.After(*key.NotAfter) {
+		return nil, errKeyExpired
+	}
+	return key.publicKey()
+}
+
+// save writes the current ring back to disk, used by the CLI subcommands
+// below to add/rotate/revoke keys.
+func (r *BrainKeyRing) save() error {
+	r.mu.RLock()
+	file := brainKeyRingFile{Keys: make([]BrainKey, 0, len(r.keys))}
+	for _, k := range r.keys {
+		file.Keys = append(file.Keys, k)
+	}
+	r.mu.RUnlock()
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, data, 0o600)
+}
+
+// Put inserts or replaces a key entry in memory (callers must call save to persist).
+func (r *BrainKeyRing) Put(k BrainKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.keys == nil {
+		r.keys = make(map[string]BrainKey)
+	}
+	r.keys[k.KID] = k
+}
+
+// Revoke marks kid as revoked in memory (callers must call save to persist).
+func (r *BrainKeyRing) Revoke(kid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k, ok := r.keys[kid]
+	if !ok {
+		return errKeyNotFound
+	}
+	k.Revoked = true
+	r.keys[kid] = k
+	return nil
+}
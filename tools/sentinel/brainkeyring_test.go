@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func genBrainKey(t *testing.T, kid string) BrainKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generating ed25519 key: %v", err)
+	}
+	return BrainKey{KID: kid, Alg: "ed25519", Pub: hex.EncodeToString(pub)}
+}
+
+func writeKeyRingFile(t *testing.T, path string, keys ...BrainKey) {
+	t.Helper()
+	r := &BrainKeyRing{path: path}
+	for _, k := range keys {
+		r.Put(k)
+	}
+	if err := r.save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+}
+
+func TestBrainKeyRingLookupUnknownKID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ring.json")
+	writeKeyRingFile(t, path, genBrainKey(t, "k1"))
+
+	r, err := loadBrainKeyRing(path)
+	if err != nil {
+		t.Fatalf("loadBrainKeyRing: %v", err)
+	}
+	if _, err := r.Lookup("missing"); err != errKeyNotFound {
+		t.Fatalf("Lookup(missing) = %v, want errKeyNotFound", err)
+	}
+}
+
+func TestBrainKeyRingLookupRevoked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ring.json")
+	k := genBrainKey(t, "k1")
+	k.Revoked = true
+	writeKeyRingFile(t, path, k)
+
+	r, err := loadBrainKeyRing(path)
+	if err != nil {
+		t.Fatalf("loadBrainKeyRing: %v", err)
+	}
+	if _, err := r.Lookup("k1"); err != errKeyRevoked {
+		t.Fatalf("Lookup(k1) = %v, want errKeyRevoked", err)
+	}
+}
+
+func TestBrainKeyRingLookupNotYetValid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ring.json")
+	k := genBrainKey(t, "k1")
+	future := time.Now().Add(time.Hour)
+	k.NotBefore = &future
+	writeKeyRingFile(t, path, k)
+
+	r, err := loadBrainKeyRing(path)
+	if err != nil {
+		t.Fatalf("loadBrainKeyRing: %v", err)
+	}
+	if _, err := r.Lookup("k1"); err != errKeyNotYet {
+		t.Fatalf("Lookup(k1) = %v, want errKeyNotYet", err)
+	}
+}
+
+func TestBrainKeyRingLookupExpired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ring.json")
+	k := genBrainKey(t, "k1")
+	past := time.Now().Add(-time.Hour)
+	k.NotAfter = &past
+	writeKeyRingFile(t, path, k)
+
+	r, err := loadBrainKeyRing(path)
+	if err != nil {
+		t.Fatalf("loadBrainKeyRing: %v", err)
+	}
+	if _, err := r.Lookup("k1"); err != errKeyExpired {
+		t.Fatalf("Lookup(k1) = %v, want errKeyExpired", err)
+	}
+}
+
+func TestBrainKeyRingOverlappingActiveKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ring.json")
+	kOld := genBrainKey(t, "old")
+	kNew := genBrainKey(t, "new")
+	writeKeyRingFile(t, path, kOld, kNew)
+
+	r, err := loadBrainKeyRing(path)
+	if err != nil {
+		t.Fatalf("loadBrainKeyRing: %v", err)
+	}
+	if _, err := r.Lookup("old"); err != nil {
+		t.Fatalf("Lookup(old) should still succeed while both keys are active: %v", err)
+	}
+	if _, err := r.Lookup("new"); err != nil {
+		t.Fatalf("Lookup(new) should succeed for a pre-published key: %v", err)
+	}
+}
+
+func TestKeyRingAddRotateRevokeRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ring.json")
+
+	// add
+	ring := openOrCreateKeyRing(path)
+	k1 := genBrainKey(t, "k1")
+	ring.Put(k1)
+	if err := ring.save(); err != nil {
+		t.Fatalf("save after add: %v", err)
+	}
+
+	reloaded, err := loadBrainKeyRing(path)
+	if err != nil {
+		t.Fatalf("loadBrainKeyRing after add: %v", err)
+	}
+	if _, err := reloaded.Lookup("k1"); err != nil {
+		t.Fatalf("Lookup(k1) after add: %v", err)
+	}
+
+	// rotate: add a second key without removing the first
+	k2 := genBrainKey(t, "k2")
+	reloaded.Put(k2)
+	if err := reloaded.save(); err != nil {
+		t.Fatalf("save after rotate: %v", err)
+	}
+
+	rotated, err := loadBrainKeyRing(path)
+	if err != nil {
+		t.Fatalf("loadBrainKeyRing after rotate: %v", err)
+	}
+	if _, err := rotated.Lookup("k1"); err != nil {
+		t.Fatalf("Lookup(k1) should still work right after rotation: %v", err)
+	}
+	if _, err := rotated.Lookup("k2"); err != nil {
+		t.Fatalf("Lookup(k2) after rotate: %v", err)
+	}
+
+	// revoke the old key
+	if err := rotated.Revoke("k1"); err != nil {
+		t.Fatalf("Revoke(k1): %v", err)
+	}
+	if err := rotated.save(); err != nil {
+		t.Fatalf("save after revoke: %v", err)
+	}
+
+	final, err := loadBrainKeyRing(path)
+	if err != nil {
+		t.Fatalf("loadBrainKeyRing after revoke: %v", err)
+	}
+	if _, err := final.Lookup("k1"); err != errKeyRevoked {
+		t.Fatalf("Lookup(k1) after revoke = %v, want errKeyRevoked", err)
+	}
+	if _, err := final.Lookup("k2"); err != nil {
+		t.Fatalf("Lookup(k2) should be unaffected by k1's revocation: %v", err)
+	}
+}
+
+func TestOpenOrCreateKeyRingMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "does-not-exist.json")
+	ring := openOrCreateKeyRing(path)
+	if _, err := ring.Lookup("anything"); err != errKeyNotFound {
+		t.Fatalf("Lookup on a freshly created empty ring = %v, want errKeyNotFound", err)
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// certStore holds the Sentinel's TLS material and the Brain's public key,
+// and keeps both fresh by polling their source files for changes. It plugs
+// into tls.Config via GetCertificate/GetConfigForClient so a rotation never
+// requires restarting the process.
+type certStore struct {
+	certFile     string
+	keyFile      string
+	caFile       string
+	brainKeyFile string
+	pollInterval time.Duration
+
+	mu          sync.RWMutex
+	cert        tls.Certificate
+	caPool      *x509.CertPool
+	brainKey    ed25519.PublicKey
+	certLastMod time.Time
+	caLastMod   time.Time
+	keyLastMod  time.Time
+
+	staticBrainKey ed25519.PublicKey // fallback when brainKeyFile is unset (BRAIN_PUBLIC_KEY env)
+
+	keyRing *BrainKeyRing // optional multi-key trust store; takes precedence over staticBrainKey/brainKeyFile when set
+}
+
+// attachKeyRing wires a BrainKeyRing into the store's reload loop so a
+// keyring edit (add/rotate/revoke) is picked up on the same poll cycle as
+// cert rotation, without a separate watcher goroutine.
+func (s *certStore) attachKeyRing(r *BrainKeyRing) {
+	s.mu.Lock()
+	s.keyRing = r
+	s.mu.Unlock()
+}
+
+// LookupBrainKey resolves a kid to a verified-fresh public key. When no
+// keyring is attached, kid is ignored and the single legacy/static key is
+// returned, preserving pre-keyring behavior.
+func (s *certStore) LookupBrainKey(kid string) (ed25519.PublicKey, error) {
+	s.mu.RLock()
+	ring := s.keyRing
+	s.mu.RUnlock()
+	if ring == nil {
+		return s.BrainKey(), nil
+	}
+	return ring.Lookup(kid)
+}
+
+// newCertStore builds a certStore and performs the initial load. staticBrainKey
+// is used verbatim (no watching) when brainKeyFile is empty, to preserve the
+// BRAIN_PUBLIC_KEY env var behavior for deployments that don't opt into file-based rotation.
+func newCertStore(certFile, keyFile, caFile, brainKeyFile string, staticBrainKey ed25519.PublicKey, pollInterval time.Duration) (*certStore, error) {
+	s := &certStore{
+		certFile:       certFile,
+		keyFile:        keyFile,
+		caFile:         caFile,
+		brainKeyFile:   brainKeyFile,
+		pollInterval:   pollInterval,
+		staticBrainKey: staticBrainKey,
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load re-reads the cert/key/CA/brain-key files from disk, validates them,
+// and swaps them in atomically. It is safe to call concurrently.
+func (s *certStore) load() error {
+	cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load Sentinel keypair from %s, %s: %v", s.certFile, s.keyFile, err)
+	}
+
+	caBytes, err := os.ReadFile(s.caFile)
+	if err != nil {
+		return fmt.Errorf("failed to read CA cert from %s: %v", s.caFile, err)
+	}
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caBytes) {
+		return fmt.Errorf("failed to append CA cert from %s", s.caFile)
+	}
+
+	brainKey := s.staticBrainKey
+	if s.brainKeyFile != "" {
+		keyBytes, err := os.ReadFile(s.brainKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read Brain public key from %s: %v", s.brainKeyFile, err)
+		}
+		brainKey, err = parseBrainKeyHex(keyBytes)
+		if err != nil {
+			return fmt.Errorf("invalid Brain public key in %s: %v", s.brainKeyFile, err)
+		}
+	}
+
+	if err := s.validate(cert, caPool, brainKey); err != nil {
+		return err
+	}
+
+	certStat, _ := os.Stat(s.certFile)
+	caStat, _ := os.Stat(s.caFile)
+
+	s.mu.Lock()
+	s.cert = cert
+	s.caPool = caPool
+	s.brainKey = brainKey
+	if certStat != nil {
+		s.certLastMod = certStat.ModTime()
+	}
+	if caStat != nil {
+		s.caLastMod = caStat.ModTime()
+	}
+	if s.brainKeyFile != "" {
+		if keyStat, err := os.Stat(s.brainKeyFile); err == nil {
+			s.keyLastMod = keyStat.ModTime()
+		}
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// validate checks that the leaf certificate is parseable and non-expired.
+// That the private key matches the certificate is already checked by
+// tls.LoadX509KeyPair in load, before validate runs. The brainKey size
+// check only applies when this store is itself the single-key source
+// (brainKeyFile or a static key was configured); when neither is set, the
+// Brain key comes from an attached BrainKeyRing instead (see
+// LookupBrainKey), which hasn't necessarily been attached yet at the first
+// load() and validates keys on lookup regardless.
+func (s *certStore) validate(cert tls.Certificate, caPool *x509.CertPool, brainKey ed25519.PublicKey) error {
+	if len(cert.Certificate) == 0 {
+		return fmt.Errorf("certificate chain is empty")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse leaf certificate: %v", err)
+	}
+	now := time.Now()
+	if now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+		return fmt.Errorf("certificate is not currently valid (valid %s to %s)", leaf.NotBefore, leaf.NotAfter)
+	}
+	if cert.Leaf == nil {
+		cert.Leaf = leaf
+	}
+	if s.brainKeyFile != "" || len(s.staticBrainKey) > 0 {
+		if len(brainKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("brain public key has wrong size: %d", len(brainKey))
+		}
+	}
+	return nil
+}
+
+// needsReload reports whether any watched file has a newer mtime than what
+// was loaded last.
+func (s *certStore) needsReload() bool {
+	certStat, err := os.Stat(s.certFile)
+	if err == nil && certStat.ModTime().After(s.certLastMod) {
+		return true
+	}
+	caStat, err := os.Stat(s.caFile)
+	if err == nil && caStat.ModTime().After(s.caLastMod) {
+		return true
+	}
+	if s.brainKeyFile != "" {
+		keyStat, err := os.Stat(s.brainKeyFile)
+		if err == nil && keyStat.ModTime().After(s.keyLastMod) {
+			return true
+		}
+	}
+	return false
+}
+
+// watch polls the watched files on pollInterval and reloads on any change,
+// logging (but not dying on) load failures so a bad write-in-progress file
+// doesn't take down a running Sentinel.
+func (s *certStore) watch(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if s.needsReload() {
+				if err := s.load(); err != nil {
+					fmt.Printf("[certStore] reload failed, keeping previous material: %v\n", err)
+				} else {
+					fmt.Println("[certStore] reloaded TLS material / Brain public key")
+				}
+			}
+
+			s.mu.RLock()
+			ring := s.keyRing
+			s.mu.RUnlock()
+			if ring != nil && ring.needsReload() {
+				if err := ring.reload(); err != nil {
+					fmt.Printf("[certStore] Brain keyring reload failed, keeping previous keys: %v\n", err)
+				} else {
+					fmt.Println("[certStore] reloaded Brain keyring")
+				}
+			}
+		}
+	}
+}
+
+// GetCertificate implements tls.Config.GetCertificate so the server
+// certificate served to new connections always reflects the latest load.
+func (s *certStore) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cert := s.cert
+	return &cert, nil
+}
+
+// GetConfigForClient implements tls.Config.GetConfigForClient so the
+// accepted client-cert issuers (ClientCAs) can rotate along with the cert.
+func (s *certStore) GetConfigForClient(_ *tls.ClientHelloInfo) (*tls.Config, error) {
+	s.mu.RLock()
+	caPool := s.caPool
+	s.mu.RUnlock()
+	return &tls.Config{
+		GetCertificate: s.GetCertificate,
+		ClientCAs:      caPool,
+		ClientAuth:     tls.RequireAndVerifyClientCert,
+		MinVersion:     tls.VersionTLS13,
+	}, nil
+}
+
+// BrainKey returns the currently active Brain public key.
+func (s *certStore) BrainKey() ed25519.PublicKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.brainKey
+}
+
+type healthzResponse struct {
+	CertFingerprintSHA256 string `json:"cert_fingerprint_sha256"`
+	CertNotAfter          string `json:"cert_not_after"`
+	BrainKeyFingerprint   string `json:"brain_key_fingerprint_sha256"`
+}
+
+// healthzHandler exposes the currently-loaded cert fingerprint and expiry
+// plus the active Brain key fingerprint, so operators can monitor rotation
+// without digging through logs.
+func (s *certStore) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	cert := s.cert
+	brainKey := s.brainKey
+	s.mu.RUnlock()
+
+	resp := healthzResponse{}
+	if len(cert.Certificate) > 0 {
+		resp.CertFingerprintSHA256 = certFingerprint(cert.Certificate[0])
+		if leaf, err := x509.ParseCertificate(cert.Certificate[0]); err == nil {
+			resp.CertNotAfter = leaf.NotAfter.Format(time.RFC3339)
+		}
+	}
+	if len(brainKey) > 0 {
+		resp.BrainKeyFingerprint = certFingerprint(brainKey)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseBrainKeyHex accepts either a bare hex string or a PEM block wrapping
+// the raw ed25519 public key bytes, matching how operators already hand us
+// BRAIN_PUBLIC_KEY today.
+func parseBrainKeyHex(data []byte) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		if len(block.Bytes) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("PEM block has wrong size for ed25519 public key: %d", len(block.Bytes))
+		}
+		return ed25519.PublicKey(block.Bytes), nil
+	}
+	trimmed := strings.TrimSpace(string(data))
+	raw, err := hex.DecodeString(trimmed)
+	if err != nil {
+		return nil, fmt.Errorf("not valid hex or PEM: %v", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("decoded key has wrong size: %d", len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
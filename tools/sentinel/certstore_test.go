@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSignedCert writes a self-signed cert/key pair to certPath/keyPath
+// with the given validity window. The cert store only parses and checks
+// expiry on the leaf, so a self-signed cert is sufficient for these tests.
+func writeSelfSignedCert(t *testing.T, certPath, keyPath string, notBefore, notAfter time.Time) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test-sentinel"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, pub, priv)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+
+	certOut, err := os.Create(certPath)
+	if err != nil {
+		t.Fatalf("creating cert file: %v", err)
+	}
+	defer certOut.Close()
+	if err := pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: der}); err != nil {
+		t.Fatalf("writing cert PEM: %v", err)
+	}
+
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("marshaling key: %v", err)
+	}
+	keyOut, err := os.Create(keyPath)
+	if err != nil {
+		t.Fatalf("creating key file: %v", err)
+	}
+	defer keyOut.Close()
+	if err := pem.Encode(keyOut, &pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes}); err != nil {
+		t.Fatalf("writing key PEM: %v", err)
+	}
+}
+
+func certStorePaths(t *testing.T) (certPath, keyPath, caPath string) {
+	dir := t.TempDir()
+	certPath = filepath.Join(dir, "sentinel.crt")
+	keyPath = filepath.Join(dir, "sentinel.key")
+	caPath = filepath.Join(dir, "ca.crt")
+	writeSelfSignedCert(t, certPath, keyPath, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	writeSelfSignedCert(t, caPath, filepath.Join(dir, "ca.key"), time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	return certPath, keyPath, caPath
+}
+
+func TestNewCertStoreWithStaticBrainKey(t *testing.T) {
+	certPath, keyPath, caPath := certStorePaths(t)
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating brain key: %v", err)
+	}
+
+	s, err := newCertStore(certPath, keyPath, caPath, "", pub, time.Minute)
+	if err != nil {
+		t.Fatalf("newCertStore: %v", err)
+	}
+	if string(s.BrainKey()) != string(pub) {
+		t.Fatal("BrainKey() did not return the configured static key")
+	}
+}
+
+func TestNewCertStoreKeyringOnlyInitSucceeds(t *testing.T) {
+	// Regression test: when neither -brain-key-file nor BRAIN_PUBLIC_KEY is
+	// set because -brain-keyring is the configured key source, newCertStore
+	// must not fail just because no single static key was supplied.
+	certPath, keyPath, caPath := certStorePaths(t)
+
+	if _, err := newCertStore(certPath, keyPath, caPath, "", nil, time.Minute); err != nil {
+		t.Fatalf("newCertStore with a keyring-only configuration should succeed, got: %v", err)
+	}
+}
+
+func TestNewCertStoreRejectsWrongSizeStaticKey(t *testing.T) {
+	certPath, keyPath, caPath := certStorePaths(t)
+
+	_, err := newCertStore(certPath, keyPath, caPath, "", []byte("too-short"), time.Minute)
+	if err == nil {
+		t.Fatal("expected an error for a static brain key of the wrong size")
+	}
+}
+
+func TestNewCertStoreRejectsExpiredCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "sentinel.crt")
+	keyPath := filepath.Join(dir, "sentinel.key")
+	caPath := filepath.Join(dir, "ca.crt")
+	writeSelfSignedCert(t, certPath, keyPath, time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour))
+	writeSelfSignedCert(t, caPath, filepath.Join(dir, "ca.key"), time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating brain key: %v", err)
+	}
+	if _, err := newCertStore(certPath, keyPath, caPath, "", pub, time.Minute); err == nil {
+		t.Fatal("expected an error for an expired certificate")
+	}
+}
+
+func TestNewCertStoreMissingCAFileFails(t *testing.T) {
+	certPath, keyPath, _ := certStorePaths(t)
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating brain key: %v", err)
+	}
+	if _, err := newCertStore(certPath, keyPath, filepath.Join(t.TempDir(), "missing-ca.crt"), "", pub, time.Minute); err == nil {
+		t.Fatal("expected an error for a missing CA file")
+	}
+}
+
+func TestNewCertStoreLoadsBrainKeyFromFile(t *testing.T) {
+	certPath, keyPath, caPath := certStorePaths(t)
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating brain key: %v", err)
+	}
+	brainKeyPath := filepath.Join(t.TempDir(), "brain.key")
+	if err := os.WriteFile(brainKeyPath, []byte(hex.EncodeToString(pub)), 0o600); err != nil {
+		t.Fatalf("writing brain key file: %v", err)
+	}
+
+	s, err := newCertStore(certPath, keyPath, caPath, brainKeyPath, nil, time.Minute)
+	if err != nil {
+		t.Fatalf("newCertStore: %v", err)
+	}
+	if string(s.BrainKey()) != string(pub) {
+		t.Fatal("BrainKey() did not return the key loaded from brainKeyFile")
+	}
+}
+
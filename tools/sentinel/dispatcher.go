@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Action is a verified instruction payload parsed into its component parts,
+// ready to hand to an Executor.
+type Action struct {
+	Verb   string
+	Args   map[string]string
+	Target string
+}
+
+// parsePayload splits a payload like "patch --service=db --target=sentinel-1"
+// into an Action. Flags are "--key=value"; a bare "--key" is recorded with
+// an empty value.
+func parsePayload(payload string) (Action, error) {
+	fields := strings.Fields(payload)
+	if len(fields) == 0 {
+		return Action{}, fmt.Errorf("empty payload")
+	}
+	action := Action{Verb: fields[0], Args: make(map[string]string)}
+	for _, f := range fields[1:] {
+		if !strings.HasPrefix(f, "--") {
+			return Action{}, fmt.Errorf("unexpected argument %q, want --key=value", f)
+		}
+		kv := strings.SplitN(strings.TrimPrefix(f, "--"), "=", 2)
+		key := kv[0]
+		val := ""
+		if len(kv) == 2 {
+			val = kv[1]
+		}
+		action.Args[key] = val
+	}
+	action.Target = action.Args["target"]
+	return action, nil
+}
+
+// Executor runs one command verb. Implementations are registered with a
+// Dispatcher at startup from a config file; an unregistered verb is
+// rejected before ever reaching an Executor.
+type Executor interface {
+	// ValidateArgs checks action args against this executor's schema
+	// before anything runs.
+	ValidateArgs(args map[string]string) error
+	// Execute runs the action, honoring ctx's deadline, and returns
+	// captured stdout/stderr and an exit code.
+	Execute(ctx context.Context, action Action) (stdout string, stderr string, exitCode int, err error)
+	// Timeout returns how long this executor's action may run before the
+	// context Dispatch passes to Execute is canceled. Dispatch builds that
+	// context directly from this value rather than a separate global
+	// default, so the one deadline it sets is the one it later checks to
+	// classify "timeout" vs "failed".
+	Timeout() time.Duration
+}
+
+// ExecResult is the structured, JSON-serializable outcome of one dispatched
+// action, returned immediately from /execute and again from /actions/{id}.
+type ExecResult struct {
+	ActionID string `json:"action_id"`
+	Verb     string `json:"verb"`
+	Status   string `json:"status"` // "running", "success", "failed", "timeout"
+	ExitCode int    `json:"exit_code"`
+	Output   string `json:"output"`
+	Duration string `json:"duration"`
+}
+
+// actionRecord is the Dispatcher's mutable bookkeeping for one in-flight or
+// completed action; ExecResult is the read-only snapshot exposed to callers.
+type actionRecord struct {
+	mu        sync.Mutex
+	id        string
+	verb      string
+	status    string
+	exitCode  int
+	output    string
+	startedAt time.Time
+	duration  time.Duration
+}
+
+func (r *actionRecord) snapshot() ExecResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return ExecResult{
+		ActionID: r.id,
+		Verb:     r.verb,
+		Status:   r.status,
+		ExitCode: r.exitCode,
+		Output:   r.output,
+		Duration: r.duration.String(),
+	}
+}
+
+// dispatchError carries an HTTP status alongside a message, same shape as
+// envelopeError, so the /execute handler can surface it directly.
+type dispatchError struct {
+	code    int
+	message string
+}
+
+func (e *dispatchError) Error() string { return e.message }
+
+// Dispatcher looks up the Executor registered for a verb and runs it
+// asynchronously, tracking progress so long-running actions can be
+// supervised via /actions/{id} instead of fire-and-forget.
+type Dispatcher struct {
+	mu        sync.RWMutex
+	executors map[string]Executor
+
+	actionsMu sync.Mutex
+	actions   map[string]*actionRecord
+}
+
+func newDispatcher() *Dispatcher {
+	return &Dispatcher{
+		executors: make(map[string]Executor),
+		actions:   make(map[string]*actionRecord),
+	}
+}
+
+// Register installs e as the handler for verb, overwriting any prior
+// registration. Only called at startup, while building the allow-list from config.
+func (d *Dispatcher) Register(verb string, e Executor) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.executors[verb] = e
+}
+
+// Dispatch validates and kicks off action.Verb's executor in the
+// background, returning immediately with a running actionRecord the caller
+// can poll via ActionResult. If onComplete is non-nil, it's called
+// once with the action's final ExecResult when the executor goroutine
+// finishes, so callers that need the real outcome (e.g. to audit it) aren't
+// stuck with the immediate "running" snapshot Dispatch itself returns.
+func (d *Dispatcher) Dispatch(action Action, onComplete func(ExecResult)) (*actionRecord, *dispatchError) {
+	d.mu.RLock()
+	executor, ok := d.executors[action.Verb]
+	d.mu.RUnlock()
+	if !ok {
+		return nil, &dispatchError{403, fmt.Sprintf("verb %q is not allow-listed", action.Verb)}
+	}
+	if err := executor.ValidateArgs(action.Args); err != nil {
+		return nil, &dispatchError{400, fmt.Sprintf("invalid args for %q: %v", action.Verb, err)}
+	}
+
+	id, err := newActionID()
+	if err != nil {
+		return nil, &dispatchError{500, fmt.Sprintf("failed to allocate action id: %v", err)}
+	}
+	rec := &actionRecord{id: id, verb: action.Verb, status: "running", startedAt: time.Now()}
+
+	d.actionsMu.Lock()
+	d.actions[id] = rec
+	d.actionsMu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), executor.Timeout())
+		defer cancel()
+
+		stdout, stderr, exitCode, execErr := executor.Execute(ctx, action)
+
+		rec.mu.Lock()
+		rec.exitCode = exitCode
+		rec.output = stdout + stderr
+		rec.duration = time.Since(rec.startedAt)
+		switch {
+		case ctx.Err() == context.DeadlineExceeded:
+			rec.status = "timeout"
+		case execErr != nil:
+			rec.status = "failed"
+		default:
+			rec.status = "success"
+		}
+		rec.mu.Unlock()
+
+		if onComplete != nil {
+			onComplete(rec.snapshot())
+		}
+	}()
+
+	return rec, nil
+}
+
+// ActionResult looks up a previously dispatched action by ID, for the
+// /actions/{id} polling endpoint.
+func (d *Dispatcher) ActionResult(id string) (ExecResult, bool) {
+	d.actionsMu.Lock()
+	rec, ok := d.actions[id]
+	d.actionsMu.Unlock()
+	if !ok {
+		return ExecResult{}, false
+	}
+	return rec.snapshot(), true
+}
+
+func newActionID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDispatchUnregisteredVerbReturns403(t *testing.T) {
+	d := newDispatcher()
+	_, dispatchErr := d.Dispatch(Action{Verb: "nope"}, nil)
+	if dispatchErr == nil {
+		t.Fatal("expected an error for an unregistered verb")
+	}
+	if dispatchErr.code != 403 {
+		t.Fatalf("code = %d, want 403", dispatchErr.code)
+	}
+}
+
+func TestDispatchInvalidArgsReturns400(t *testing.T) {
+	d := newDispatcher()
+	d.Register("patch", &commandExecutor{binary: "/usr/bin/true", allowedArgs: map[string]bool{"service": true}, timeout: time.Second})
+
+	_, dispatchErr := d.Dispatch(Action{Verb: "patch", Args: map[string]string{"unexpected": "x"}}, nil)
+	if dispatchErr == nil {
+		t.Fatal("expected an error for an arg outside the executor's schema")
+	}
+	if dispatchErr.code != 400 {
+		t.Fatalf("code = %d, want 400", dispatchErr.code)
+	}
+}
+
+// awaitActionDone polls action id until its status leaves "running" or the test
+// deadline expires.
+func awaitActionDone(t *testing.T, d *Dispatcher, id string) ExecResult {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		result, ok := d.ActionResult(id)
+		if !ok {
+			t.Fatalf("action %s not found", id)
+		}
+		if result.Status != "running" {
+			return result
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("action %s did not finish within the test deadline", id)
+	return ExecResult{}
+}
+
+func TestDispatchSuccessClassification(t *testing.T) {
+	d := newDispatcher()
+	d.Register("ok", &commandExecutor{binary: "/usr/bin/true", timeout: time.Second})
+
+	rec, dispatchErr := d.Dispatch(Action{Verb: "ok"}, nil)
+	if dispatchErr != nil {
+		t.Fatalf("Dispatch: %v", dispatchErr)
+	}
+	result := awaitActionDone(t, d, rec.id)
+	if result.Status != "success" {
+		t.Fatalf("status = %q, want %q", result.Status, "success")
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("exit code = %d, want 0", result.ExitCode)
+	}
+}
+
+func TestDispatchFailedClassification(t *testing.T) {
+	d := newDispatcher()
+	d.Register("bad", &commandExecutor{binary: "/usr/bin/false", timeout: time.Second})
+
+	rec, dispatchErr := d.Dispatch(Action{Verb: "bad"}, nil)
+	if dispatchErr != nil {
+		t.Fatalf("Dispatch: %v", dispatchErr)
+	}
+	result := awaitActionDone(t, d, rec.id)
+	if result.Status != "failed" {
+		t.Fatalf("status = %q, want %q", result.Status, "failed")
+	}
+}
+
+func TestDispatchTimeoutClassification(t *testing.T) {
+	d := newDispatcher()
+	// /usr/bin/yes with no args runs forever, so a short per-executor
+	// timeout is what actually kills it - this is the case that was
+	// previously misclassified as "failed" because Dispatch checked an
+	// outer context with a different (longer) deadline than the one the
+	// executor itself enforced.
+	d.Register("hang", &commandExecutor{binary: "/usr/bin/yes", timeout: 20 * time.Millisecond})
+
+	rec, dispatchErr := d.Dispatch(Action{Verb: "hang"}, nil)
+	if dispatchErr != nil {
+		t.Fatalf("Dispatch: %v", dispatchErr)
+	}
+	result := awaitActionDone(t, d, rec.id)
+	if result.Status != "timeout" {
+		t.Fatalf("status = %q, want %q", result.Status, "timeout")
+	}
+}
+
+func TestDispatchInvokesOnComplete(t *testing.T) {
+	d := newDispatcher()
+	d.Register("ok", &commandExecutor{binary: "/usr/bin/true", timeout: time.Second})
+
+	done := make(chan ExecResult, 1)
+	_, dispatchErr := d.Dispatch(Action{Verb: "ok"}, func(final ExecResult) {
+		done <- final
+	})
+	if dispatchErr != nil {
+		t.Fatalf("Dispatch: %v", dispatchErr)
+	}
+
+	select {
+	case final := <-done:
+		if final.Status != "success" {
+			t.Fatalf("onComplete status = %q, want %q", final.Status, "success")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("onComplete was never called")
+	}
+}
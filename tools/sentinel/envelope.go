@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// envelopeVersion is the only wire version this Sentinel currently accepts.
+const envelopeVersion = 1
+
+// Envelope is the replay-resistant unit the Brain signs and the Sentinel
+// verifies on /execute. issued_at/expires_at are Unix seconds so clock
+// comparisons don't depend on a particular time.Time encoding surviving
+// the canonicalization round trip.
+type Envelope struct {
+	V                int    `json:"v"`
+	KID              string `json:"kid"`
+	IssuedAt         int64  `json:"issued_at"`
+	ExpiresAt        int64  `json:"expires_at"`
+	Nonce            string `json:"nonce"` // hex-encoded, unique per envelope
+	TargetSentinelID string `json:"target_sentinel_id"`
+	Payload          string `json:"payload"`
+}
+
+// SignedEnvelope is the full /execute request body: an Envelope plus the
+// signature over its canonical serialization. When InstrCert is set, the
+// signature is produced by that cert's ephemeral key rather than a Brain
+// root key directly (see instrcert.go); when it's nil, KID refers straight
+// into the root BrainKeyRing, preserving the pre-delegation behavior.
+type SignedEnvelope struct {
+	Envelope  Envelope               `json:"envelope"`
+	Signature string                 `json:"signature"` // hex-encoded ed25519 signature
+	InstrCert *SignedInstructionCert `json:"instr_cert,omitempty"`
+}
+
+// canonicalizeEnvelope produces the deterministic, sorted-key byte serialization
+// that gets signed, so the signer and verifier never disagree about field
+// order or whitespace. It's a minimal stand-in for RFC 8785 JCS: the
+// Envelope's field set is small and fixed, so we hand-sort rather than
+// pull in a generic canonicalizer.
+func canonicalizeEnvelope(e Envelope) []byte {
+	return []byte(fmt.Sprintf(
+		`{"expires_at":%d,"issued_at":%d,"kid":%q,"nonce":%q,"payload":%q,"target_sentinel_id":%q,"v":%d}`,
+		e.ExpiresAt, e.IssuedAt, e.KID, e.Nonce, e.Payload, e.TargetSentinelID, e.V,
+	))
+}
+
+// envelopeError classifies why an otherwise-signature-valid envelope was
+// rejected, so the handler can return an HTTP status other than
+// "unauthorized" for each case. A bad version and clock skew share 400;
+// the other cases each have their own code (see the constants below).
+type envelopeError struct {
+	code    int
+	message string
+}
+
+func (e *envelopeError) Error() string { return e.message }
+
+// checkEnvelope validates everything about an envelope except the
+// signature itself: version, clock skew, expiry, and target routing.
+func checkEnvelope(e Envelope, sentinelID string, maxSkew time.Duration, now time.Time) *envelopeError {
+	if e.V != envelopeVersion {
+		return &envelopeError{httpBadVersion, fmt.Sprintf("unsupported envelope version %d", e.V)}
+	}
+	issuedAt := time.Unix(e.IssuedAt, 0)
+	if issuedAt.After(now.Add(maxSkew)) || issuedAt.Before(now.Add(-maxSkew)) {
+		return &envelopeError{httpClockSkew, "issued_at outside allowed clock skew window"}
+	}
+	if now.After(time.Unix(e.ExpiresAt, 0)) {
+		return &envelopeError{httpExpired, "envelope has expired"}
+	}
+	if e.TargetSentinelID != sentinelID {
+		return &envelopeError{httpWrongTarget, fmt.Sprintf("envelope targets %q, not this Sentinel", e.TargetSentinelID)}
+	}
+	return nil
+}
+
+// HTTP status codes used to distinguish envelope rejection reasons from a
+// true signature mismatch (which stays at the conventional 401).
+const (
+	httpBadVersion  = 400
+	httpClockSkew   = 400
+	httpExpired     = 410 // Gone: the envelope was valid once, isn't anymore
+	httpWrongTarget = 421 // Misdirected Request
+	httpReplay      = 409 // Conflict: this exact nonce was already consumed
+)
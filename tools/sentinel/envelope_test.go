@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func baseEnvelope(now time.Time) Envelope {
+	return Envelope{
+		V:                envelopeVersion,
+		KID:              "brain-1",
+		IssuedAt:         now.Unix(),
+		ExpiresAt:        now.Add(5 * time.Minute).Unix(),
+		Nonce:            "deadbeef",
+		TargetSentinelID: "sentinel-1",
+		Payload:          "patch --service=db",
+	}
+}
+
+func TestCheckEnvelopeAccepted(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	e := baseEnvelope(now)
+	if err := checkEnvelope(e, "sentinel-1", 2*time.Minute, now); err != nil {
+		t.Fatalf("expected a valid envelope to be accepted, got: %v", err)
+	}
+}
+
+func TestCheckEnvelopeRejectsBadVersion(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	e := baseEnvelope(now)
+	e.V = 2
+	err := checkEnvelope(e, "sentinel-1", 2*time.Minute, now)
+	if err == nil || err.code != httpBadVersion {
+		t.Fatalf("expected httpBadVersion, got %v", err)
+	}
+}
+
+func TestCheckEnvelopeRejectsClockSkew(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	e := baseEnvelope(now)
+	e.IssuedAt = now.Add(10 * time.Minute).Unix()
+	err := checkEnvelope(e, "sentinel-1", 2*time.Minute, now)
+	if err == nil || err.code != httpClockSkew {
+		t.Fatalf("expected httpClockSkew, got %v", err)
+	}
+}
+
+func TestCheckEnvelopeRejectsExpired(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	e := baseEnvelope(now)
+	e.ExpiresAt = now.Add(-time.Second).Unix()
+	err := checkEnvelope(e, "sentinel-1", 2*time.Minute, now)
+	if err == nil || err.code != httpExpired {
+		t.Fatalf("expected httpExpired, got %v", err)
+	}
+}
+
+func TestCheckEnvelopeRejectsWrongTarget(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	e := baseEnvelope(now)
+	err := checkEnvelope(e, "sentinel-2", 2*time.Minute, now)
+	if err == nil || err.code != httpWrongTarget {
+		t.Fatalf("expected httpWrongTarget, got %v", err)
+	}
+}
+
+func TestCanonicalizeEnvelopeIsDeterministic(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	e := baseEnvelope(now)
+	a := canonicalizeEnvelope(e)
+	b := canonicalizeEnvelope(e)
+	if string(a) != string(b) {
+		t.Fatalf("canonicalizeEnvelope is not deterministic: %q vs %q", a, b)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCommandExecutorValidateArgsRejectsUnknownArg(t *testing.T) {
+	e := &commandExecutor{binary: "/usr/bin/true", allowedArgs: map[string]bool{"service": true}}
+	if err := e.ValidateArgs(map[string]string{"service": "db"}); err != nil {
+		t.Fatalf("an allowed arg should validate, got: %v", err)
+	}
+	if err := e.ValidateArgs(map[string]string{"unexpected": "x"}); err == nil {
+		t.Fatal("expected an error for an arg not in the schema")
+	}
+}
+
+func TestCommandExecutorExecuteSuccess(t *testing.T) {
+	e := &commandExecutor{binary: "/usr/bin/true", timeout: time.Second}
+	_, _, exitCode, err := e.Execute(context.Background(), Action{Verb: "noop"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if exitCode != 0 {
+		t.Fatalf("exitCode = %d, want 0", exitCode)
+	}
+}
+
+func TestCommandExecutorExecuteNonZeroExit(t *testing.T) {
+	e := &commandExecutor{binary: "/usr/bin/false", timeout: time.Second}
+	_, _, exitCode, err := e.Execute(context.Background(), Action{Verb: "noop"})
+	if err == nil {
+		t.Fatal("expected an error for a non-zero exit")
+	}
+	if exitCode == 0 {
+		t.Fatal("expected a non-zero exit code")
+	}
+}
+
+func TestCommandExecutorExecuteHonorsContextDeadline(t *testing.T) {
+	// /usr/bin/yes with no args runs forever (until killed), so it reliably
+	// outlives a short deadline regardless of timing.
+	e := &commandExecutor{binary: "/usr/bin/yes", timeout: time.Second}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, _, _, err := e.Execute(ctx, Action{Verb: "noop"})
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Fatalf("ctx.Err() = %v, want DeadlineExceeded", ctx.Err())
+	}
+	if err == nil {
+		t.Fatal("expected an error when the context deadline kills the command")
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// InstructionCert is the SSH-certificate-style delegation the Brain issues
+// per operator/task: an ephemeral ed25519 public key plus the metadata that
+// bounds what it may be used for. The Brain root key signs this, not the
+// envelope itself, so a leaked ephemeral key only grants whatever this cert
+// already scoped it to, for as long as it remains valid.
+type InstructionCert struct {
+	PubKey          string            `json:"pub"` // hex-encoded ephemeral ed25519 public key
+	Principals      []string          `json:"principals"`
+	ValidAfter      int64             `json:"valid_after"`  // unix seconds
+	ValidBefore     int64             `json:"valid_before"` // unix seconds
+	AllowedCommands []string          `json:"allowed_commands"`
+	CriticalOptions map[string]string `json:"critical_options,omitempty"`
+}
+
+// SignedInstructionCert is an InstructionCert plus the Brain root
+// signature over its canonical encoding.
+type SignedInstructionCert struct {
+	Cert      InstructionCert `json:"cert"`
+	KID       string          `json:"kid"` // Brain root key that signed Cert
+	Signature string          `json:"signature"`
+}
+
+// canonicalizeInstrCert produces the same kind of sorted-key, deterministic
+// serialization as canonicalizeEnvelope, so root signing/verification never
+// disagrees about encoding.
+func canonicalizeInstrCert(c InstructionCert) []byte {
+	principals := append([]string(nil), c.Principals...)
+	sort.Strings(principals)
+	commands := append([]string(nil), c.AllowedCommands...)
+	sort.Strings(commands)
+
+	optKeys := make([]string, 0, len(c.CriticalOptions))
+	for k := range c.CriticalOptions {
+		optKeys = append(optKeys, k)
+	}
+	sort.Strings(optKeys)
+	opts := make([]string, 0, len(optKeys))
+	for _, k := range optKeys {
+		opts = append(opts, fmt.Sprintf("%q:%q", k, c.CriticalOptions[k]))
+	}
+
+	return []byte(fmt.Sprintf(
+		`{"allowed_commands":[%s],"critical_options":{%s},"principals":[%s],"pub":%q,"valid_after":%d,"valid_before":%d}`,
+		quoteJoin(commands), strings.Join(opts, ","), quoteJoin(principals), c.PubKey, c.ValidAfter, c.ValidBefore,
+	))
+}
+
+func quoteJoin(ss []string) string {
+	quoted := make([]string, len(ss))
+	for i, s := range ss {
+		quoted[i] = fmt.Sprintf("%q", s)
+	}
+	return strings.Join(quoted, ",")
+}
+
+// verifyInstructionCert checks the Brain root signature over sic, its
+// validity window, and returns the ephemeral public key it delegates to.
+func verifyInstructionCert(sic *SignedInstructionCert, rootKey ed25519.PublicKey, now time.Time) (ed25519.PublicKey, *envelopeError) {
+	sig, err := hex.DecodeString(sic.Signature)
+	if err != nil {
+		return nil, &envelopeError{httpBadVersion, "invalid instruction cert signature hex"}
+	}
+	if !ed25519.Verify(rootKey, canonicalizeInstrCert(sic.Cert), sig) {
+		return nil, &envelopeError{401, "instruction cert signature invalid"}
+	}
+
+	if now.Before(time.Unix(sic.Cert.ValidAfter, 0)) {
+		return nil, &envelopeError{httpClockSkew, "instruction cert not yet valid"}
+	}
+	if now.After(time.Unix(sic.Cert.ValidBefore, 0)) {
+		return nil, &envelopeError{httpExpired, "instruction cert has expired"}
+	}
+
+	raw, err := hex.DecodeString(sic.Cert.PubKey)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, &envelopeError{httpBadVersion, "instruction cert carries an invalid ephemeral public key"}
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// commandAllowed reports whether verb is in the instruction cert's
+// allow-list of commands the ephemeral key may authorize.
+func (c InstructionCert) commandAllowed(verb string) bool {
+	for _, allowed := range c.AllowedCommands {
+		if allowed == verb {
+			return true
+		}
+	}
+	return false
+}
+
+// principalAllowed reports whether identity (the caller's role, resolved
+// from their mTLS client cert via roleFromCert) is named in the
+// instruction cert's principals, the same binding an SSH certificate's
+// principal list gives a bearer: a valid cert authorizes whoever presents
+// it only if they're who it was issued to, not anyone holding the
+// ephemeral key.
+func (c InstructionCert) principalAllowed(identity string) bool {
+	for _, p := range c.Principals {
+		if p == identity {
+			return true
+		}
+	}
+	return false
+}
+
+// recognizedCriticalOptions is the set of critical_options keys this
+// Sentinel understands and enforces. None are implemented yet; per the SSH
+// certificate model, a critical option this Sentinel doesn't recognize must
+// cause rejection rather than being silently ignored, since the Brain may
+// have intended it to narrow what the cert authorizes.
+var recognizedCriticalOptions = map[string]bool{}
+
+// checkCriticalOptions rejects a cert carrying any critical_options key
+// this Sentinel doesn't explicitly understand.
+func (c InstructionCert) checkCriticalOptions() error {
+	for k := range c.CriticalOptions {
+		if !recognizedCriticalOptions[k] {
+			return fmt.Errorf("unrecognized critical option %q", k)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestPrincipalAllowed(t *testing.T) {
+	c := InstructionCert{Principals: []string{"oncall", "sre-lead"}}
+
+	if !c.principalAllowed("oncall") {
+		t.Error("oncall is a listed principal and should be allowed")
+	}
+	if c.principalAllowed("readonly") {
+		t.Error("readonly is not a listed principal and should not be allowed")
+	}
+}
+
+func TestCheckCriticalOptionsAcceptsEmpty(t *testing.T) {
+	c := InstructionCert{}
+	if err := c.checkCriticalOptions(); err != nil {
+		t.Fatalf("empty critical_options should never be rejected: %v", err)
+	}
+}
+
+func TestCheckCriticalOptionsRejectsUnrecognized(t *testing.T) {
+	c := InstructionCert{CriticalOptions: map[string]string{"force-destructive": "true"}}
+	if err := c.checkCriticalOptions(); err == nil {
+		t.Fatal("expected an unrecognized critical option to be rejected")
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// runKeyringCLI implements `sentinel keyring add|rotate|revoke`, the
+// operator-facing way to manage the Brain trust store without hand-editing
+// the JSON file. It's invoked from main() before flag.Parse runs for the
+// server mode, matching the single-binary-many-subcommands shape used
+// elsewhere in this tool.
+func runKeyringCLI(args []string) {
+	if len(args) == 0 {
+		log.Fatal("usage: sentinel keyring <add|rotate|revoke> [flags]")
+	}
+
+	switch args[0] {
+	case "add", "rotate":
+		fs := flag.NewFlagSet("keyring "+args[0], flag.ExitOnError)
+		path := fs.String("keyring", "brain_keyring.json", "Path to the Brain keyring JSON file")
+		kid := fs.String("kid", "", "Key ID for the new/rotated key")
+		pubHex := fs.String("pub", "", "Hex-encoded ed25519 public key")
+		alg := fs.String("alg", "ed25519", "Key algorithm")
+		notBefore := fs.String("not-before", "", "RFC3339 time the key becomes valid (optional)")
+		notAfter := fs.String("not-after", "", "RFC3339 time the key expires (optional)")
+		fs.Parse(args[1:])
+
+		if *kid == "" || *pubHex == "" {
+			log.Fatal("keyring add/rotate requires -kid and -pub")
+		}
+		raw, err := hex.DecodeString(*pubHex)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			log.Fatalf("invalid -pub: %v", err)
+		}
+
+		key := BrainKey{KID: *kid, Alg: *alg, Pub: *pubHex}
+		if *notBefore != "" {
+			t, err := time.Parse(time.RFC3339, *notBefore)
+			if err != nil {
+				log.Fatalf("invalid -not-before: %v", err)
+			}
+			key.NotBefore = &t
+		}
+		if *notAfter != "" {
+			t, err := time.Parse(time.RFC3339, *notAfter)
+			if err != nil {
+				log.Fatalf("invalid -not-after: %v", err)
+			}
+			key.NotAfter = &t
+		}
+
+		ring := openOrCreateKeyRing(*path)
+		ring.Put(key)
+		if err := ring.save(); err != nil {
+			log.Fatalf("failed to save keyring: %v", err)
+		}
+		fmt.Printf("keyring: kid %q written to %s\n", *kid, *path)
+
+	case "revoke":
+		fs := flag.NewFlagSet("keyring revoke", flag.ExitOnError)
+		path := fs.String("keyring", "brain_keyring.json", "Path to the Brain keyring JSON file")
+		kid := fs.String("kid", "", "Key ID to revoke")
+		fs.Parse(args[1:])
+
+		if *kid == "" {
+			log.Fatal("keyring revoke requires -kid")
+		}
+		ring, err := loadBrainKeyRing(*path)
+		if err != nil {
+			log.Fatalf("failed to load keyring: %v", err)
+		}
+		if err := ring.Revoke(*kid); err != nil {
+			log.Fatalf("failed to revoke kid %q: %v", *kid, err)
+		}
+		if err := ring.save(); err != nil {
+			log.Fatalf("failed to save keyring: %v", err)
+		}
+		fmt.Printf("keyring: kid %q revoked in %s\n", *kid, *path)
+
+	default:
+		log.Fatalf("unknown keyring subcommand %q (want add|rotate|revoke)", args[0])
+	}
+}
+
+// openOrCreateKeyRing loads an existing keyring file, or returns an empty
+// one backed by path if it doesn't exist yet.
+func openOrCreateKeyRing(path string) *BrainKeyRing {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return &BrainKeyRing{path: path, keys: make(map[string]BrainKey)}
+	}
+	ring, err := loadBrainKeyRing(path)
+	if err != nil {
+		log.Fatalf("failed to load keyring: %v", err)
+	}
+	return ring
+}
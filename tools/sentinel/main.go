@@ -3,55 +3,115 @@ package main
 import (
 	"crypto/ed25519"
 	"crypto/tls"
-	"crypto/x509"
 	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 )
 
 type SignedInstruction struct {
-	Payload   string `json:"payload"`   // e.g., "patch --service=db"
-	Signature string `json:"signature"` // Hex-encoded Ed25519 signature
+	Payload   string `json:"payload"`       // e.g., "patch --service=db"
+	Signature string `json:"signature"`     // Hex-encoded Ed25519 signature
+	KID       string `json:"kid,omitempty"` // Brain key ring ID that produced Signature
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "keyring" {
+		runKeyringCLI(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		runAuditCLI(os.Args[2:])
+		return
+	}
+
 	// Flags for configuration
 	certFile := flag.String("cert", "sentinel.crt", "Path to Sentinel certificate")
 	keyFile := flag.String("key", "sentinel.key", "Path to Sentinel private key")
 	caFile := flag.String("ca", "ca.crt", "Path to CA certificate")
 	port := flag.String("port", "8443", "Port to listen on")
+	brainKeyFile := flag.String("brain-key-file", "", "Path to a file holding a single Brain public key (hex or PEM; a directory is not accepted); watched for rotation. Still loaded and validated, but not used to verify signatures, when -brain-keyring is set")
+	brainKeyRingFile := flag.String("brain-keyring", "", "Path to a JWKS-style JSON file of Brain public keys (kid, alg, pub, not_before, not_after, revoked); takes precedence over -brain-key-file/BRAIN_PUBLIC_KEY")
+	reloadInterval := flag.Duration("reload-interval", 30*time.Second, "How often to poll cert/key/CA/brain-key files for changes")
+	sentinelID := flag.String("sentinel-id", "", "This Sentinel's target_sentinel_id, as addressed by the Brain (required)")
+	clockSkew := flag.Duration("max-clock-skew", 2*time.Minute, "How far an envelope's issued_at may drift from local time before it's rejected")
+	nonceLogFile := flag.String("nonce-log", "sentinel_nonces.log", "Path to the on-disk replay-protection nonce log")
+	nonceCacheSize := flag.Int("nonce-cache-size", 100000, "Number of recent nonces kept in the in-memory replay cache")
+	policyFile := flag.String("policy", "", "Path to the role->allowed-verbs authorization policy file (required)")
+	dispatcherConfigFile := flag.String("dispatcher-config", "", "Path to the executor allow-list config file (required)")
+	actionTimeout := flag.Duration("action-timeout", 30*time.Second, "Default per-action execution timeout")
+	auditLogFile := flag.String("audit-log", "sentinel_audit.log", "Path to the hash-chained audit log")
+	auditRotateBytes := flag.Int64("audit-rotate-bytes", 100*1024*1024, "Rotate the audit log once it reaches this size (0 disables rotation)")
 	flag.Parse()
 
-	// Load Brain Public Key from Env
-	brainKeyHex := os.Getenv("BRAIN_PUBLIC_KEY")
-	if brainKeyHex == "" {
-		log.Fatal("BRAIN_PUBLIC_KEY environment variable is required")
+	if *sentinelID == "" {
+		log.Fatal("-sentinel-id is required")
+	}
+	if *policyFile == "" {
+		log.Fatal("-policy is required")
+	}
+	policy, err := loadPolicy(*policyFile)
+	if err != nil {
+		log.Fatalf("Failed to load policy: %v", err)
+	}
+	if *dispatcherConfigFile == "" {
+		log.Fatal("-dispatcher-config is required")
+	}
+	dispatcher, err := loadDispatcher(*dispatcherConfigFile, *actionTimeout)
+	if err != nil {
+		log.Fatalf("Failed to load dispatcher config: %v", err)
+	}
+	auditLog, err := openAuditLog(*auditLogFile, *auditRotateBytes)
+	if err != nil {
+		log.Fatalf("Failed to open audit log: %v", err)
+	}
+
+	// Load Brain Public Key from Env as a fallback when neither -brain-keyring nor -brain-key-file is set.
+	var staticBrainKey ed25519.PublicKey
+	if *brainKeyRingFile == "" && *brainKeyFile == "" {
+		brainKeyHex := os.Getenv("BRAIN_PUBLIC_KEY")
+		if brainKeyHex == "" {
+			log.Fatal("one of -brain-keyring, -brain-key-file, or BRAIN_PUBLIC_KEY environment variable is required")
+		}
+		raw, err := hex.DecodeString(brainKeyHex)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			log.Fatalf("Invalid BRAIN_PUBLIC_KEY: %v", err)
+		}
+		staticBrainKey = ed25519.PublicKey(raw)
 	}
 
-	// 1. Setup mTLS (Mutual TLS)
-	caCert, err := os.ReadFile(*caFile)
+	// 1. Setup mTLS (Mutual TLS), backed by a certStore so rotation never requires a restart.
+	store, err := newCertStore(*certFile, *keyFile, *caFile, *brainKeyFile, staticBrainKey, *reloadInterval)
 	if err != nil {
-		log.Fatalf("Failed to read CA cert from %s: %v", *caFile, err)
+		log.Fatalf("Failed to initialize cert store: %v", err)
 	}
-	caCertPool := x509.NewCertPool()
-	if !caCertPool.AppendCertsFromPEM(caCert) {
-		log.Fatal("Failed to append CA cert")
+	if *brainKeyRingFile != "" {
+		ring, err := loadBrainKeyRing(*brainKeyRingFile)
+		if err != nil {
+			log.Fatalf("Failed to load Brain keyring: %v", err)
+		}
+		store.attachKeyRing(ring)
 	}
+	stopWatch := make(chan struct{})
+	defer close(stopWatch)
+	go store.watch(stopWatch)
 
-	cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+	nonces, err := newNonceStore(*nonceLogFile, *nonceCacheSize)
 	if err != nil {
-		log.Fatalf("Failed to load Sentinel keypair from %s, %s: %v", *certFile, *keyFile, err)
+		log.Fatalf("Failed to initialize nonce store: %v", err)
 	}
+	defer nonces.Close()
 
 	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{cert},
-		ClientCAs:    caCertPool,
-		ClientAuth:   tls.RequireAndVerifyClientCert,
-		MinVersion:   tls.VersionTLS13,
+		GetCertificate:     store.GetCertificate,
+		GetConfigForClient: store.GetConfigForClient,
+		ClientAuth:         tls.RequireAndVerifyClientCert,
+		MinVersion:         tls.VersionTLS13,
 	}
 
 	// 2. The Execution Handler
@@ -61,37 +121,195 @@ func main() {
 			return
 		}
 
-		body, _ := io.ReadAll(r.Body)
+		audit := AuditEntry{Verdict: "denied:unknown"}
+		var execResult *ExecResult
+		defer func() {
+			if execResult != nil {
+				if out, err := json.Marshal(execResult); err == nil {
+					audit.ExecutorResult = string(out)
+				}
+			}
+			if _, err := auditLog.Append(audit); err != nil {
+				log.Printf("audit log append failed: %v", err)
+			}
+		}()
 
-		// Expecting raw bytes where the first 128 chars are hex signature
-		if len(body) < 129 {
-			http.Error(w, "Invalid Payload", http.StatusBadRequest)
+		var role string
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			audit.ClientCertFingerprint = certFingerprint(r.TLS.PeerCertificates[0].Raw)
+			var err error
+			role, err = roleFromCert(r.TLS.PeerCertificates[0])
+			if err != nil {
+				audit.Verdict = "denied:no_role"
+				http.Error(w, "Forbidden: "+err.Error(), http.StatusForbidden)
+				return
+			}
+		} else {
+			audit.Verdict = "denied:no_client_cert"
+			http.Error(w, "Forbidden: no client certificate", http.StatusForbidden)
 			return
 		}
 
-		sigHex := string(body[:128])
-		msg := body[128:]
+		var se SignedEnvelope
+		if err := json.NewDecoder(r.Body).Decode(&se); err != nil {
+			audit.Verdict = "denied:invalid_json"
+			http.Error(w, "Invalid Envelope JSON", http.StatusBadRequest)
+			return
+		}
+		audit.KID = se.Envelope.KID
+		audit.Nonce = se.Envelope.Nonce
+		audit.PayloadHash = sha256Hex(se.Envelope.Payload)
 
-		sig, err := hex.DecodeString(sigHex)
-        if err != nil {
-            http.Error(w, "Invalid Signature Hex", http.StatusBadRequest)
-            return
-        }
-		pubKey, err := hex.DecodeString(brainKeyHex)
+		sig, err := hex.DecodeString(se.Signature)
 		if err != nil {
-			log.Printf("Invalid Brain Public Key: %v", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			audit.Verdict = "denied:invalid_signature_hex"
+			http.Error(w, "Invalid Signature Hex", http.StatusBadRequest)
 			return
 		}
 
-		if ed25519.Verify(pubKey, msg, sig) {
-			fmt.Printf("[TRUSTED] Signature Valid. Executing: %s\n", string(msg))
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("Action executed successfully"))
+		now := time.Now()
+		var pubKey ed25519.PublicKey
+		if se.InstrCert != nil {
+			rootKey, err := store.LookupBrainKey(se.InstrCert.KID)
+			if err != nil {
+				log.Printf("Brain root key lookup failed for kid %q: %v", se.InstrCert.KID, err)
+				audit.Verdict = "denied:unknown_key"
+				http.Error(w, "Unauthorized: Unknown or Invalid Key", http.StatusUnauthorized)
+				return
+			}
+			ephemeralKey, instrErr := verifyInstructionCert(se.InstrCert, rootKey, now)
+			if instrErr != nil {
+				fmt.Printf("[ALERT] Instruction cert rejected: %v\n", instrErr)
+				audit.Verdict = "denied:instr_cert_rejected"
+				http.Error(w, instrErr.Error(), instrErr.code)
+				return
+			}
+			if err := se.InstrCert.Cert.checkCriticalOptions(); err != nil {
+				audit.Verdict = "denied:critical_option_unrecognized"
+				http.Error(w, "Forbidden: "+err.Error(), http.StatusForbidden)
+				return
+			}
+			if !se.InstrCert.Cert.principalAllowed(role) {
+				audit.Verdict = "denied:principal_not_allowed"
+				http.Error(w, fmt.Sprintf("Forbidden: instruction cert does not authorize principal %q", role), http.StatusForbidden)
+				return
+			}
+			verb := parseVerb(se.Envelope.Payload)
+			if !se.InstrCert.Cert.commandAllowed(verb) {
+				audit.Verdict = "denied:verb_not_in_instr_cert"
+				http.Error(w, fmt.Sprintf("Forbidden: instruction cert does not allow verb %q", verb), http.StatusForbidden)
+				return
+			}
+			pubKey = ephemeralKey
 		} else {
+			pubKey, err = store.LookupBrainKey(se.Envelope.KID)
+			if err != nil {
+				log.Printf("Brain key lookup failed for kid %q: %v", se.Envelope.KID, err)
+				audit.Verdict = "denied:unknown_key"
+				http.Error(w, "Unauthorized: Unknown or Invalid Key", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		msg := canonicalizeEnvelope(se.Envelope)
+		if !ed25519.Verify(pubKey, msg, sig) {
 			fmt.Println("[ALERT] INVALID SIGNATURE DETECTED. Blocking Execution.")
+			audit.Verdict = "denied:bad_signature"
 			http.Error(w, "Unauthorized: Signature Mismatch", http.StatusUnauthorized)
+			return
+		}
+
+		if envErr := checkEnvelope(se.Envelope, *sentinelID, *clockSkew, now); envErr != nil {
+			fmt.Printf("[ALERT] Envelope rejected: %v\n", envErr)
+			audit.Verdict = "denied:envelope_rejected"
+			http.Error(w, envErr.Error(), envErr.code)
+			return
+		}
+
+		verb := parseVerb(se.Envelope.Payload)
+		if !policy.Allows(role, verb) {
+			fmt.Printf("[ALERT] Role %q not authorized for verb %q\n", role, verb)
+			audit.Verdict = "denied:role_not_authorized"
+			http.Error(w, fmt.Sprintf("Forbidden: role %q may not invoke %q", role, verb), http.StatusForbidden)
+			return
+		}
+
+		replay, err := nonces.CheckAndRecord(se.Envelope.Nonce)
+		if err != nil {
+			log.Printf("Nonce store error: %v", err)
+			audit.Verdict = "denied:nonce_store_error"
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if replay {
+			fmt.Printf("[ALERT] Nonce replay detected: %s\n", se.Envelope.Nonce)
+			audit.Verdict = "denied:replay"
+			http.Error(w, "Conflict: Nonce Already Used", httpReplay)
+			return
+		}
+
+		action, err := parsePayload(se.Envelope.Payload)
+		if err != nil {
+			audit.Verdict = "denied:invalid_payload"
+			http.Error(w, "Invalid Payload: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		fmt.Printf("[TRUSTED] Signature Valid. Dispatching: %s\n", se.Envelope.Payload)
+		completionAudit := AuditEntry{
+			ClientCertFingerprint: audit.ClientCertFingerprint,
+			KID:                   audit.KID,
+			Nonce:                 audit.Nonce,
+			PayloadHash:           audit.PayloadHash,
+		}
+		rec, dispatchErr := dispatcher.Dispatch(action, func(final ExecResult) {
+			completionAudit.Verdict = "completed:" + final.Status
+			if out, err := json.Marshal(final); err == nil {
+				completionAudit.ExecutorResult = string(out)
+			}
+			if _, err := auditLog.Append(completionAudit); err != nil {
+				log.Printf("audit log append failed for action %s completion: %v", final.ActionID, err)
+			}
+		})
+		if dispatchErr != nil {
+			audit.Verdict = "denied:dispatch_rejected"
+			http.Error(w, dispatchErr.Error(), dispatchErr.code)
+			return
+		}
+
+		audit.Verdict = "allowed"
+		result := rec.snapshot()
+		execResult = &result
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(result)
+	})
+
+	// 3. Operator-facing health endpoint, for monitoring cert/key rotation.
+	http.HandleFunc("/healthz", store.healthzHandler)
+
+	// 4. Walk the audit chain and report the first broken link, if any.
+	http.HandleFunc("/audit/verify", func(w http.ResponseWriter, r *http.Request) {
+		result, err := auditLog.VerifyChain()
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})
+
+	// 5. Poll the status of a previously dispatched action.
+	http.HandleFunc("/actions/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/actions/")
+		result, ok := dispatcher.ActionResult(id)
+		if !ok {
+			http.Error(w, "Unknown action id", http.StatusNotFound)
+			return
 		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
 	})
 
 	addr := fmt.Sprintf(":%s", *port)
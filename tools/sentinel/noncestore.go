@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"container/list"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// nonceStore blocks envelope replay across process restarts. Replay checks
+// consult only a bounded in-memory LRU, so a nonce evicted from it is no
+// longer detected; an append-only on-disk log records every accepted nonce
+// and re-seeds the LRU on startup, since the LRU alone would forget
+// everything on process exit.
+type nonceStore struct {
+	mu         sync.Mutex
+	path       string
+	maxEntries int
+	order      *list.List               // front = most recently seen
+	elems      map[string]*list.Element // nonce -> position in order
+	logFile    *os.File
+
+	writesSinceCompaction int
+	compactionThreshold   int // compact once writesSinceCompaction reaches this
+}
+
+// newNonceStore opens (or creates) the on-disk log at path and replays it
+// to seed the in-memory LRU, so a nonce consumed before a restart is still
+// rejected after one. The log is compacted down to maxEntries lines
+// periodically (see CheckAndRecord/compactLocked) so a long-lived Sentinel
+// doesn't grow it forever; the replay above always keeps at most the last
+// maxEntries nonces in memory regardless of how large an uncompacted file
+// has grown.
+func newNonceStore(path string, maxEntries int) (*nonceStore, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open nonce log %s: %v", path, err)
+	}
+
+	compactionThreshold := maxEntries * 2
+	if compactionThreshold <= 0 {
+		compactionThreshold = maxEntries
+	}
+	s := &nonceStore{
+		path:                path,
+		maxEntries:          maxEntries,
+		order:               list.New(),
+		elems:               make(map[string]*list.Element),
+		logFile:             f,
+		compactionThreshold: compactionThreshold,
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		s.rememberInMemory(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to replay nonce log %s: %v", path, err)
+	}
+
+	if err := s.compactLocked(); err != nil {
+		fmt.Printf("[nonceStore] startup compaction failed, continuing with uncompacted log: %v\n", err)
+	}
+	return s, nil
+}
+
+// rememberInMemory records nonce as seen, evicting the least-recently-seen
+// entry once maxEntries is exceeded. Callers must hold s.mu, except during
+// the initial replay in newNonceStore where no other goroutine can race.
+func (s *nonceStore) rememberInMemory(nonce string) {
+	if elem, ok := s.elems[nonce]; ok {
+		s.order.MoveToFront(elem)
+		return
+	}
+	elem := s.order.PushFront(nonce)
+	s.elems[nonce] = elem
+	for s.order.Len() > s.maxEntries {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.elems, oldest.Value.(string))
+	}
+}
+
+// CheckAndRecord reports whether nonce has been seen before. If it hasn't,
+// it's durably recorded (fsync'd) before returning so a crash between the
+// check and the caller acting on it can't reopen the replay window.
+func (s *nonceStore) CheckAndRecord(nonce string) (replay bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.elems[nonce]; ok {
+		return true, nil
+	}
+
+	if _, err := fmt.Fprintln(s.logFile, nonce); err != nil {
+		return false, fmt.Errorf("failed to persist nonce: %v", err)
+	}
+	if err := s.logFile.Sync(); err != nil {
+		return false, fmt.Errorf("failed to fsync nonce log: %v", err)
+	}
+
+	s.rememberInMemory(nonce)
+
+	s.writesSinceCompaction++
+	if s.writesSinceCompaction >= s.compactionThreshold {
+		if err := s.compactLocked(); err != nil {
+			fmt.Printf("[nonceStore] compaction failed, continuing with uncompacted log: %v\n", err)
+		} else {
+			s.writesSinceCompaction = 0
+		}
+	}
+	return false, nil
+}
+
+// compactLocked rewrites the on-disk log to hold only the nonces currently
+// in the in-memory LRU (oldest first), so disk usage stays bounded by
+// maxEntries instead of growing for as long as the process runs. Callers
+// must hold s.mu.
+func (s *nonceStore) compactLocked() error {
+	tmpPath := s.path + ".compact.tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open compaction temp file: %v", err)
+	}
+	for e := s.order.Back(); e != nil; e = e.Prev() {
+		if _, err := fmt.Fprintln(f, e.Value.(string)); err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to write compacted nonce log: %v", err)
+		}
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to fsync compacted nonce log: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close compacted nonce log: %v", err)
+	}
+	if err := s.logFile.Close(); err != nil {
+		return fmt.Errorf("failed to close current nonce log: %v", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to install compacted nonce log: %v", err)
+	}
+	newFile, err := os.OpenFile(s.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to reopen nonce log after compaction: %v", err)
+	}
+	s.logFile = newFile
+	return nil
+}
+
+func (s *nonceStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logFile.Close()
+}
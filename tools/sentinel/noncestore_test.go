@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNonceStoreDetectsReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nonces.log")
+	s, err := newNonceStore(path, 10)
+	if err != nil {
+		t.Fatalf("newNonceStore: %v", err)
+	}
+	defer s.Close()
+
+	replay, err := s.CheckAndRecord("nonce-a")
+	if err != nil {
+		t.Fatalf("CheckAndRecord: %v", err)
+	}
+	if replay {
+		t.Fatal("first sighting of a nonce should not be a replay")
+	}
+
+	replay, err = s.CheckAndRecord("nonce-a")
+	if err != nil {
+		t.Fatalf("CheckAndRecord: %v", err)
+	}
+	if !replay {
+		t.Fatal("second sighting of the same nonce should be a replay")
+	}
+}
+
+func TestNonceStoreSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nonces.log")
+	s, err := newNonceStore(path, 10)
+	if err != nil {
+		t.Fatalf("newNonceStore: %v", err)
+	}
+	if _, err := s.CheckAndRecord("nonce-a"); err != nil {
+		t.Fatalf("CheckAndRecord: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := newNonceStore(path, 10)
+	if err != nil {
+		t.Fatalf("reopening nonce store: %v", err)
+	}
+	defer reopened.Close()
+
+	replay, err := reopened.CheckAndRecord("nonce-a")
+	if err != nil {
+		t.Fatalf("CheckAndRecord after restart: %v", err)
+	}
+	if !replay {
+		t.Fatal("a nonce recorded before restart should still be rejected after one")
+	}
+}
+
+func TestNonceStoreCompactsOnDiskLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nonces.log")
+	// A small maxEntries makes the compaction threshold (2x maxEntries)
+	// trivial to cross within the test.
+	s, err := newNonceStore(path, 5)
+	if err != nil {
+		t.Fatalf("newNonceStore: %v", err)
+	}
+	defer s.Close()
+
+	for i := 0; i < 100; i++ {
+		if _, err := s.CheckAndRecord(fmt.Sprintf("nonce-%d", i)); err != nil {
+			t.Fatalf("CheckAndRecord: %v", err)
+		}
+	}
+
+	lines := countLines(t, path)
+	if lines > 10 {
+		t.Fatalf("expected compaction to bound the on-disk log, got %d lines after 100 writes with maxEntries=5", lines)
+	}
+
+	// The most recently seen nonces must still be rejected as replays after
+	// compaction rewrote the file out from under the open handle.
+	replay, err := s.CheckAndRecord("nonce-99")
+	if err != nil {
+		t.Fatalf("CheckAndRecord: %v", err)
+	}
+	if !replay {
+		t.Fatal("a recently-seen nonce should still be rejected as a replay after compaction")
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("opening %s: %v", path, err)
+	}
+	defer f.Close()
+	n := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("scanning %s: %v", path, err)
+	}
+	return n
+}